@@ -0,0 +1,65 @@
+// Copyright 2023 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scan
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// CoalesceParagraphs merges each run of Paragraph tokens on consecutive
+// lines that start at the same column into a single Paragraph token whose
+// text joins the lines with newlines. An indented continuation line drops
+// the Space token before it, so the lines of a list item, block quote, or
+// comment body merge like those of a paragraph at column 0.
+// A line that starts at a different column, such as a paragraph at column 0
+// after a comment, begins a new run, as does a blank line, including one
+// holding only whitespace, or any other token.
+// The merged token keeps the line of the first token in the run.
+func CoalesceParagraphs(toks []Token) []Token {
+	out := make([]Token, 0, len(toks))
+	cols := columns(toks)
+	run, runCol := -1, 0 // index in out and column of the paragraph being extended
+	for i := 0; i < len(toks); i++ {
+		j := i
+		if toks[i].Type == Space && i+1 < len(toks) {
+			// A Space spanning a line break holds a blank line, which ends the run.
+			if strings.Contains(toks[i].Text, "\n") {
+				run = -1
+			}
+			j = i + 1
+		}
+		if run >= 0 && run == len(out)-1 && cols[i] == 0 && toks[j].Type == Paragraph && cols[j] == runCol {
+			out[run].Text += "\n" + toks[j].Text
+			i = j
+			continue
+		}
+		run = -1
+		if toks[i].Type == Paragraph {
+			run, runCol = len(out), cols[i]
+		}
+		out = append(out, toks[i])
+	}
+	return out
+}
+
+// columns returns the column, counted in runes, at which each token starts.
+func columns(toks []Token) []int {
+	cols := make([]int, len(toks))
+	line, col := 0, 0
+	for i, t := range toks {
+		if t.Line != line {
+			line, col = t.Line, 0
+		}
+		cols[i] = col
+		if j := strings.LastIndexByte(t.Text, '\n'); j >= 0 {
+			line += strings.Count(t.Text, "\n")
+			col = utf8.RuneCountInString(t.Text[j+1:])
+		} else {
+			col += utf8.RuneCountInString(t.Text)
+		}
+	}
+	return cols
+}
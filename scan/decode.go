@@ -0,0 +1,114 @@
+// Copyright 2023 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scan
+
+import (
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding identifies the character encoding of the input.
+type Encoding int
+
+const (
+	UTF8    Encoding = iota // UTF8 is the encoding the scanner expects
+	Latin1                  // Latin1 is ISO 8859-1
+	UTF16LE                 // UTF16LE is little-endian UTF-16
+	UTF16BE                 // UTF16BE is big-endian UTF-16
+)
+
+// decoder transcodes input to UTF-8 one rune at a time.
+type decoder struct {
+	r       io.ByteReader
+	enc     Encoding
+	buf     []byte // UTF-8 bytes of the most recent rune not yet read
+	started bool   // has the first rune been read?
+	unit    uint16 // UTF-16 code unit pushed back by readRune
+	pending bool   // is there a pushed-back code unit?
+}
+
+// Decode returns a reader of the UTF-8 encoding of r, whose input is in
+// the encoding e. A UTF-16 byte order mark at the start of the input is
+// dropped. Unpaired UTF-16 surrogates and an odd trailing byte decode to
+// U+FFFD. The result can be passed to New.
+func Decode(r io.ByteReader, e Encoding) io.ByteReader {
+	if e == UTF8 {
+		return r
+	}
+	return &decoder{r: r, enc: e}
+}
+
+// ReadByte reads the next byte of UTF-8.
+func (d *decoder) ReadByte() (byte, error) {
+	if len(d.buf) == 0 {
+		r, err := d.readRune()
+		if err != nil {
+			return 0, err
+		}
+		d.buf = utf8.AppendRune(d.buf[:0], r)
+	}
+	c := d.buf[0]
+	d.buf = d.buf[1:]
+	return c, nil
+}
+
+// readRune reads and decodes the next rune of the input.
+func (d *decoder) readRune() (rune, error) {
+	if d.enc == Latin1 {
+		c, err := d.r.ReadByte()
+		return rune(c), err
+	}
+	u, err := d.readUnit()
+	if err != nil {
+		return 0, err
+	}
+	if !d.started {
+		d.started = true
+		if u == '\ufeff' {
+			return d.readRune()
+		}
+	}
+	if !utf16.IsSurrogate(rune(u)) {
+		return rune(u), nil
+	}
+	u2, err := d.readUnit()
+	if err == io.EOF {
+		return utf8.RuneError, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	r := utf16.DecodeRune(rune(u), rune(u2))
+	if r == utf8.RuneError {
+		// The second unit does not complete a pair, so it starts the next rune.
+		d.unit, d.pending = u2, true
+	}
+	return r, nil
+}
+
+// readUnit reads a UTF-16 code unit.
+// An odd trailing byte reads as U+FFFD.
+func (d *decoder) readUnit() (uint16, error) {
+	if d.pending {
+		d.pending = false
+		return d.unit, nil
+	}
+	c1, err := d.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	c2, err := d.r.ReadByte()
+	if err == io.EOF {
+		return utf8.RuneError, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if d.enc == UTF16BE {
+		c1, c2 = c2, c1
+	}
+	return uint16(c1) | uint16(c2)<<8, nil
+}
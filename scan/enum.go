@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 // lexEnum scans an enumeration.
@@ -18,7 +19,7 @@ func lexEnum(l *Scanner) stateFn {
 		switch r := l.peek(); {
 		case r == '\n':
 			return lexEndOfLine(l, Enum)
-		case r == eof, unicode.IsSpace(r):
+		case r == eof, isSpace(r):
 			return l.emit(Enum)
 		}
 		l.next()
@@ -79,7 +80,7 @@ func (l *Scanner) enumSuffix() (int, bool) {
 	if i < 0 {
 		return i, false
 	}
-	if l.pos+i+1 < len(l.input) && !unicode.IsSpace(rune(l.input[l.pos+i+1])) {
+	if r, _ := utf8.DecodeRuneInString(l.input[l.pos+i+1:]); r != utf8.RuneError && !isSpace(r) {
 		return i, false
 	}
 	return i, true
@@ -125,14 +126,17 @@ func (l *Scanner) enum(r rune, i int) (enum, bool) {
 			}
 		case i > 0:
 			return e, false
+		case unicode.IsLower(r):
+			e = enum{typ: lowerAlpha, val: int(r-'a') + 1}
 		default:
-			e = enum{typ: upperAlpha, val: int(r - '0')}
-			if unicode.IsLower(r) {
-				e.typ = lowerAlpha
-			}
+			e = enum{typ: upperAlpha, val: int(r-'A') + 1}
 		}
 	case r == '#' && i == 0:
+		// An auto-enumerator continues the current list or starts an arabic one.
 		e = enum{typ: l.lastEnum.typ, val: l.lastEnum.val + 1, auto: true}
+		if e.typ == none {
+			e.typ = arabic
+		}
 	default:
 		return e, false
 	}
@@ -165,8 +169,13 @@ func parseRoman(s string) (int, bool) {
 		return 0, false
 	}
 	var sum int
-	for _, r := range s {
-		sum += nums[r]
+	for i, r := range s {
+		// A numeral smaller than the one after it is subtracted, as in IV.
+		if n := nums[r]; i+1 < len(s) && n < nums[rune(s[i+1])] {
+			sum -= n
+		} else {
+			sum += n
+		}
 	}
 	return sum, true
 }
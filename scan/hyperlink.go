@@ -0,0 +1,66 @@
+// Copyright 2023 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scan
+
+import "strings"
+
+// JoinName returns the name spelled by the HyperlinkName tokens of the first
+// hyperlink target in toks, stopping at the HyperlinkStart of the next target.
+// The lines of a name split across lines, backquoted or not, are joined and
+// each run of whitespace becomes a single space. Case and backslash escapes
+// are kept.
+func JoinName(toks []Token) string {
+	var names []string
+	for _, t := range firstTarget(toks) {
+		if t.Type == HyperlinkName {
+			names = append(names, t.Text)
+		}
+	}
+	return strings.Join(strings.FieldsFunc(strings.Join(names, " "), isSpace), " ")
+}
+
+// JoinURI returns the URI spelled by the HyperlinkURI tokens of the first
+// hyperlink target in toks, stopping at the HyperlinkStart of the next target.
+// Lines are joined without whitespace and backslash escapes are removed.
+// A backslash at the end of a line escapes the line break, which continues
+// the URI with a single space.
+func JoinURI(toks []Token) string {
+	var b strings.Builder
+	for _, t := range firstTarget(toks) {
+		if t.Type != HyperlinkURI {
+			continue
+		}
+		for i := 0; i < len(t.Text); i++ {
+			c := t.Text[i]
+			if c != '\\' {
+				b.WriteByte(c)
+				continue
+			}
+			if i++; i == len(t.Text) {
+				b.WriteByte(' ')
+				break
+			}
+			b.WriteByte(t.Text[i])
+		}
+	}
+	return b.String()
+}
+
+// firstTarget returns the leading tokens of toks through the first hyperlink
+// target with a name or URI, ending before the next HyperlinkStart.
+func firstTarget(toks []Token) []Token {
+	var started bool
+	for i, t := range toks {
+		switch t.Type {
+		case HyperlinkStart:
+			if started {
+				return toks[:i]
+			}
+		case HyperlinkName, HyperlinkURI:
+			started = true
+		}
+	}
+	return toks
+}
@@ -6,6 +6,7 @@
 package scan
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"strings"
@@ -50,6 +51,16 @@ const (
 	InlineReferenceClose             // InlineReferenceClose closes an inline reference
 )
 
+// IsBlock reports whether the type starts a block-level element:
+// a section title, transition, list item, block quote, or explicit markup.
+func (i Type) IsBlock() bool {
+	switch i {
+	case Title, Transition, Bullet, Enum, BlockQuote, Comment, HyperlinkStart:
+		return true
+	}
+	return false
+}
+
 func (i Token) String() string {
 	switch {
 	case i.Type == EOF:
@@ -62,6 +73,23 @@ func (i Token) String() string {
 	return fmt.Sprintf("%s: %q", i.Type, i.Text)
 }
 
+// Equal reports whether i and t have the same type and text.
+func (i Token) Equal(t Token) bool {
+	return i.Type == t.Type && i.Text == t.Text
+}
+
+// EqualLine reports whether i and t have the same type and text
+// and appear on the same line.
+func (i Token) EqualLine(t Token) bool {
+	return i.Equal(t) && i.Line == t.Line
+}
+
+// Key returns a canonical key for the token's type and text.
+// Tokens that are Equal have the same key.
+func (i Token) Key() string {
+	return i.Type.String() + ":" + i.Text
+}
+
 const eof = -1
 
 // stateFn represents the state of the scanner as a function that returns the next state.
@@ -84,6 +112,13 @@ type Scanner struct {
 	indent     int           // current indentation level in the input
 	lastMarkup Type          // most recent markup type
 	lastEnum   enum          // most recent enumeration
+	err        error         // error reported by the most recent Error token
+	rest       string        // unscanned input when the Error token was returned
+	disabled   Construct     // constructs scanned as paragraphs instead
+	verify     bool          // panic if a token's line does not match the input
+	text       []byte        // input read so far, for verify
+	offset     int           // offset of input in the text, for verify
+	tokenStart int           // offset of the token in the text, for verify
 }
 
 // loadLine reads the next line of input and stores it in (appends it to) the input.
@@ -99,6 +134,9 @@ func (l *Scanner) loadLine() {
 		}
 		if c != '\r' { // There will never be a \r in l.input.
 			l.buf = append(l.buf, c)
+			if l.verify {
+				l.text = append(l.text, c)
+			}
 		}
 		if c == '\n' {
 			break
@@ -106,6 +144,7 @@ func (l *Scanner) loadLine() {
 	}
 	// Reset to beginning of input buffer if there is nothing pending.
 	if l.start == l.pos {
+		l.offset += l.pos
 		l.input = string(l.buf)
 		l.start = 0
 		l.pos = 0
@@ -139,25 +178,35 @@ func (l *Scanner) peek() rune {
 }
 
 // emit passes an item back to the client.
+// Newlines in the item, such as those ending blank lines, count toward
+// the line of the next item.
 func (l *Scanner) emit(t Type) stateFn {
 	if t == BlankLine {
-		l.line++
 		l.lastEnum = enum{typ: none, val: 0}
 	}
-	if l.start == 0 && notSpace(rune(l.input[l.start])) {
+	if l.atLineStart() && notSpace(rune(l.input[l.start])) {
 		l.indent = 0
 	}
 	text := l.input[l.start:l.pos]
 	l.token = Token{t, l.line, text}
+	l.tokenStart = l.offset + l.start
+	l.line += strings.Count(text, "\n")
 	l.types[0] = l.types[1]
 	l.types[1] = t
 	l.start = l.pos
 	return nil
 }
 
+// isSpace reports whether the rune is a space character.
+// A non-breaking space (U+00A0) is never a space character in
+// reStructuredText: it neither indents nor separates elements.
+func isSpace(c rune) bool {
+	return c != '\u00a0' && unicode.IsSpace(c)
+}
+
 // notSpace reports whether the rune is not a space character.
 func notSpace(c rune) bool {
-	return !unicode.IsSpace(c)
+	return !isSpace(c)
 }
 
 // ignore skips over the pending input before this point.
@@ -170,7 +219,10 @@ func (l *Scanner) ignore() {
 
 // errorf returns an error token and empties the input.
 func (l *Scanner) errorf(format string, args ...any) stateFn {
-	l.token = Token{Error, l.start, fmt.Sprintf(format, args...)}
+	l.token = Token{Error, l.line, fmt.Sprintf(format, args...)}
+	l.err = fmt.Errorf("%s:%d: %s", l.name, l.line, l.token.Text)
+	l.tokenStart = l.offset + l.start
+	l.rest = l.input[l.start:]
 	l.start = 0
 	l.pos = 0
 	l.input = l.input[:0]
@@ -186,26 +238,140 @@ func New(name string, r io.ByteReader) *Scanner {
 func (l *Scanner) Next() Token {
 	l.lastRune = eof
 	l.lastWidth = 0
-	l.token = Token{EOF, l.pos, "EOF"}
+	l.token = Token{EOF, l.line, "EOF"}
+	l.tokenStart = l.offset + l.start
 	state := lexAny
-	for {
+	for state != nil {
 		state = state(l)
-		if state == nil {
-			return l.token
+	}
+	if l.verify {
+		l.verifyLine()
+	}
+	return l.token
+}
+
+// Verify makes the scanner panic if it returns a token whose line differs
+// from the line the token starts on in the input. The expected line is
+// counted from the input itself, so it guards the lookahead save and restore
+// sites against desynchronizing the line count. Verify is meant for tests
+// and must be called before Next.
+func (l *Scanner) Verify() {
+	l.verify = true
+}
+
+// verifyLine panics if the token's line is not the line of the input
+// at which the token starts.
+func (l *Scanner) verifyLine() {
+	if line := 1 + bytes.Count(l.text[:l.tokenStart], []byte("\n")); l.token.Line != line {
+		panic(fmt.Sprintf("%s: %v on line %d, expected line %d", l.name, l.token, l.token.Line, line))
+	}
+}
+
+// Construct is a set of markup constructs recognized by the scanner.
+type Construct uint
+
+const (
+	Bullets     Construct = 1 << iota // Bullets are bullet lists
+	Enums                             // Enums are enumerated lists
+	BlockQuotes                       // BlockQuotes are block quotes and their attributions
+	Comments                          // Comments are comments
+	Transitions                       // Transitions are transitions
+	Sections                          // Sections are section titles and adornments
+	Hyperlinks                        // Hyperlinks are hyperlink targets
+)
+
+// Disable stops the scanner from recognizing the constructs in c.
+// Text that would have started a disabled construct scans as a paragraph
+// or, for indentation, as a space. Disable must be called before Next.
+func (l *Scanner) Disable(c Construct) {
+	l.disabled |= c
+}
+
+// isDisabled reports whether the scanner is on a comment, transition, or
+// hyperlink target that has been disabled. Such a line scans as a paragraph
+// rather than as a section adornment made of the same characters.
+func (l *Scanner) isDisabled(r rune) bool {
+	switch {
+	case !l.enabled(Comments) && l.isComment():
+		return true
+	case !l.enabled(Transitions) && l.isTransition(r):
+		return true
+	case !l.enabled(Hyperlinks) && l.isHyperlinkStart():
+		return true
+	}
+	return false
+}
+
+// enabled reports whether the scanner recognizes the construct c.
+func (l *Scanner) enabled(c Construct) bool {
+	return l.disabled&c == 0
+}
+
+// NextBlock returns the next token that starts a block-level element,
+// skipping all other tokens, or the EOF or Error token that ends the input.
+func (l *Scanner) NextBlock() Token {
+	for {
+		t := l.Next()
+		if t.Type.IsBlock() || t.Type == EOF || t.Type == Error {
+			return t
 		}
 	}
 }
 
+// Err returns the error reported by the most recent Error token,
+// or nil if the scanner has not returned one.
+func (l *Scanner) Err() error {
+	return l.err
+}
+
+// Indent returns the width in columns of the indentation of the current line,
+// as set by the most recent Space or BlockQuote token that started a line.
+// Only the last line of a token spanning whitespace-only lines counts, and a
+// tab advances to the next multiple of 8 columns.
+func (l *Scanner) Indent() int {
+	return l.indent
+}
+
+// Rest returns the portion of the input buffer that has not been scanned yet.
+// The buffer holds only the lines read so far, so the underlying reader
+// may have more input beyond it. After an Error token, Rest returns the
+// input from the start of the item that failed to scan.
+func (l *Scanner) Rest() string {
+	if l.err != nil {
+		return l.rest
+	}
+	return l.input[l.pos:]
+}
+
 const (
 	comment                   = ".."
 	hyperlinkStart            = ".. _"
 	anonHyperlinkStart        = "__ "
 	anonHyperlinkPrefix       = "__:"
 	bullets                   = "*+-•‣⁃"
+	emDash                    = "—"
 	adornments                = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
 	minSection, minTransition = 2, 4
+	tabWidth                  = 8 // distance between tab stops
 )
 
+// indentWidth returns the width in columns of the whitespace s, counting only
+// its last line. A tab advances to the next multiple of tabWidth columns.
+func indentWidth(s string) int {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	var w int
+	for _, r := range s {
+		if r == '\t' {
+			w += tabWidth - w%tabWidth
+		} else {
+			w++
+		}
+	}
+	return w
+}
+
 // lexAny scans any item.
 func lexAny(l *Scanner) stateFn {
 	switch r := l.next(); {
@@ -213,21 +379,28 @@ func lexAny(l *Scanner) stateFn {
 		return nil
 	case r == '\n':
 		return lexBlankLine
-	case l.isBlockQuote():
+	case l.isPlainLine(r):
+		if l.enabled(Sections) && l.isTitle() {
+			return lexTitle
+		}
+		return lexParagraph
+	case l.enabled(BlockQuotes) && l.isBlockQuote():
 		return lexSpace(l, BlockQuote)
-	case l.isAttribution():
+	case l.enabled(BlockQuotes) && l.isAttribution():
 		return lexAttribution
-	case unicode.IsSpace(r):
+	case isSpace(r):
 		return lexSpace(l, Space)
-	case l.isBullet(r):
+	case l.isDisabled(r):
+		return lexParagraph
+	case l.enabled(Bullets) && l.isBullet(r):
 		return lexBullet
-	case l.isComment():
+	case l.enabled(Comments) && l.isComment():
 		return lexComment
-	case l.isTransition(r):
+	case l.enabled(Transitions) && l.isTransition(r):
 		return lexTransition
-	case l.isSectionAdornment(r):
+	case l.enabled(Sections) && l.isSectionAdornment(r):
 		return lexSection
-	case l.isHyperlinkStart():
+	case l.enabled(Hyperlinks) && l.isHyperlinkStart():
 		return lexHyperlinkStart
 	case l.isHyperlinkPrefix():
 		return lexHyperlinkPrefix
@@ -243,9 +416,9 @@ func lexAny(l *Scanner) stateFn {
 		return lexInlineReferenceText
 	case l.isInlineReferenceClose():
 		return lexInlineReferenceClose
-	case l.isTitle():
+	case l.enabled(Sections) && l.isTitle():
 		return lexTitle
-	case l.isEnum(r):
+	case l.enabled(Enums) && l.isEnum(r):
 		return lexEnum
 	default:
 		return lexParagraph
@@ -276,28 +449,61 @@ func lexUntilTerminator(l *Scanner, typ Type) stateFn {
 }
 
 // lexBlankLine scans a blank line.
+// Every blank line, including one holding only spaces, is its own BlankLine
+// token. Blank lines at the start of the input emit one BlankLine each and
+// leave the first construct to scan as it would at the start of the input;
+// blank lines at the end emit one each before EOF.
 func lexBlankLine(l *Scanner) stateFn {
-	if l.types[1] == Comment {
+	// A blank line ends an empty comment, a section title, or a transition,
+	// so indented text after it can start a block quote.
+	switch l.types[1] {
+	case Comment, SectionAdornment, Transition:
 		l.lastMarkup = EOF
 	}
 	return l.emit(BlankLine)
 }
 
-// lexSpace scans a run of space characters.
+// lexSpace scans a run of space characters. A line holding only spaces is a
+// blank line: its spaces are dropped so that its newline scans as a BlankLine.
+// Spaces at the end of a line that is followed by a blank line are dropped
+// along with the newline that ends the line.
 func lexSpace(l *Scanner, typ Type) stateFn {
-	var i int
-	for i = 1; unicode.IsSpace(l.peek()); i++ {
+	lineStart := l.atLineStart()
+	l.skipSpaces()
+	switch r := l.peek(); {
+	case lineStart && (r == '\n' || r == eof):
+		l.ignore()
+		return lexAny
+	case r == '\n':
+		pos := l.pos
 		l.next()
+		l.skipSpaces()
+		if r := l.peek(); r == '\n' || r == eof {
+			l.pos = pos + 1
+			l.ignore()
+			return lexAny
+		}
 	}
-	if l.start == 0 || l.input[l.start-1] == '\n' {
-		l.indent = i
+	if lineStart {
+		l.indent = indentWidth(l.input[l.start:l.pos])
 	}
 	return l.emit(typ)
 }
 
+// atLineStart reports whether the pending item starts a line.
+func (l *Scanner) atLineStart() bool {
+	return l.start == 0 || l.input[l.start-1] == '\n'
+}
+
+// skipSpaces consumes space characters up to the end of the line.
+func (l *Scanner) skipSpaces() {
+	for r := l.peek(); r != '\n' && isSpace(r); r = l.peek() {
+		l.next()
+	}
+}
+
 // lexAttribution scans an attribution.
 func lexAttribution(l *Scanner) stateFn {
-	l.indent = 0
 	return lexUntilTerminator(l, Attribution)
 }
 
@@ -401,9 +607,20 @@ func lexInlineReferenceClose(l *Scanner) stateFn {
 }
 
 // lexTitle scans a title.
+// Leading whitespace is scanned as a space, and trailing whitespace is ignored.
 func lexTitle(l *Scanner) stateFn {
 	l.lastMarkup = Title
-	return lexUntilTerminator(l, Title)
+	line, _, _ := strings.Cut(l.input[l.start:], "\n")
+	title := strings.TrimRightFunc(line, isSpace)
+	l.pos = l.start + len(title)
+	i := l.emit(Title)
+	l.pos += len(line) - len(title)
+	l.ignore()
+	if l.peek() == '\n' {
+		l.pos++
+		l.ignore()
+	}
+	return i
 }
 
 // lexParagraph scans a paragraph.
@@ -414,6 +631,26 @@ func lexParagraph(l *Scanner) stateFn {
 	return lexUntilTerminator(l, Paragraph)
 }
 
+// isPlainLine reports whether the scanner is on a line of plain text that
+// can only start a section title or a paragraph. The line follows a paragraph
+// or a blank line, starts with a letter, and its first word has no enumeration
+// suffix, so none of the other constructs need to be tried.
+func (l *Scanner) isPlainLine(r rune) bool {
+	switch l.types[1] {
+	case Paragraph, BlankLine:
+	default:
+		return false
+	}
+	if !unicode.IsLetter(r) {
+		return false
+	}
+	word := l.input[l.pos:]
+	if i := strings.IndexFunc(word, isSpace); i >= 0 {
+		word = word[:i]
+	}
+	return !strings.ContainsAny(word, enumSuffixes)
+}
+
 // isBlockQuote reports whether the scanner is on a block quote.
 func (l *Scanner) isBlockQuote() bool {
 	if l.lastMarkup != EOF {
@@ -421,16 +658,27 @@ func (l *Scanner) isBlockQuote() bool {
 	}
 	switch l.types[0] {
 	case Paragraph, Attribution, Comment:
+	case SectionAdornment, Transition:
+		if l.types[1] != BlankLine {
+			return false
+		}
 	default:
 		if l.types[1] != Paragraph || (l.types[1] != BlankLine && l.indent > 0) {
 			return false
 		}
 	}
 	i := strings.IndexFunc(l.input[l.start:], notSpace)
-	return i > 0 && i != l.indent
+	if l.types[0] == Attribution {
+		// An attribution ends its block quote, so indented text after it
+		// starts a new one at any indentation.
+		return i > 0
+	}
+	return i > 0 && indentWidth(l.input[l.start:l.start+i]) != l.indent
 }
 
 // isAttribution reports whether the scanner is on an attribution.
+// An attribution starts with two or three hyphens or an em dash and has text
+// after it; four or more hyphens start a transition or section adornment instead.
 func (l *Scanner) isAttribution() bool {
 	if l.types[0] == Attribution && l.types[1] == Space {
 		return true
@@ -438,12 +686,16 @@ func (l *Scanner) isAttribution() bool {
 	if l.types[1] == BlockQuote {
 		return false
 	}
-	s := l.input[l.start:]
-	if !strings.HasPrefix(s, "--") && !strings.HasPrefix(s, "—") {
+	s, _, _ := strings.Cut(l.input[l.start:], "\n")
+	switch n := len(s) - len(strings.TrimLeft(s, "-")); {
+	case n == 2, n == 3:
+		s = s[n:]
+	case strings.HasPrefix(s, emDash):
+		s = s[len(emDash):]
+	default:
 		return false
 	}
-	s = strings.TrimSpace(strings.TrimLeft(s, "-"))
-	if len(s) == 0 || strings.ContainsAny(s, "-") {
+	if strings.TrimFunc(s, isSpace) == "" {
 		return false
 	}
 	pos, lastWidth := l.pos, l.lastWidth
@@ -453,16 +705,24 @@ func (l *Scanner) isAttribution() bool {
 	}
 	l.next()
 	i := strings.IndexFunc(l.input[l.pos-1:], notSpace)
+	ok := i < 1 || indentWidth(l.input[l.pos-1:l.pos-1+i]) == l.indent
 	l.pos, l.lastWidth = pos, lastWidth
-	return i < 1 || i == l.indent
+	return ok
 }
 
 // isBullet reports whether the scanner is on a bullet.
+// The bullet must be followed by whitespace or the end of the input,
+// so text like "*emphasis*" is not a bullet.
 func (l *Scanner) isBullet(r rune) bool {
-	return strings.ContainsRune(bullets, r) && unicode.IsSpace(l.peek())
+	if !strings.ContainsRune(bullets, r) {
+		return false
+	}
+	r = l.peek()
+	return r == eof || isSpace(r)
 }
 
 // isComment reports whether the scanner is on a comment.
+// The comment marker is followed by whitespace or the end of the input.
 func (l *Scanner) isComment() bool {
 	if l.types[1] == Title {
 		return false
@@ -471,10 +731,19 @@ func (l *Scanner) isComment() bool {
 	if strings.HasPrefix(s, hyperlinkStart) && len(s) > len(hyperlinkStart) {
 		return false
 	}
-	return strings.HasPrefix(s, comment+" ") || strings.HasPrefix(s, comment+"\n")
+	s, ok := strings.CutPrefix(s, comment)
+	if !ok {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return s == "" || isSpace(r)
 }
 
 // isTransition reports whether the scanner is on a transition.
+// A transition is a line of at least minTransition adornment characters
+// with a blank line or the edge of the input on both sides. Two adornment
+// lines with nothing in between are instead an overline and underline
+// around a missing title, so isSectionAdornment scans them.
 func (l *Scanner) isTransition(r rune) bool {
 	switch l.types[1] {
 	case EOF, BlankLine:
@@ -496,6 +765,7 @@ func (l *Scanner) isTransition(r rune) bool {
 	for r != eof && r != '\n' {
 		r = l.next()
 	}
+	l.skipSpaces()
 	r = l.peek()
 	l.pos, l.lastWidth = pos, lastWidth
 	if r != eof && r != '\n' {
@@ -505,6 +775,8 @@ func (l *Scanner) isTransition(r rune) bool {
 }
 
 // isSectionAdornment reports whether the scanner is on a section adornment.
+// An adornment line after a blank line is one only if the next line is not
+// blank, so it cannot be mistaken for a lone transition.
 func (l *Scanner) isSectionAdornment(r rune) bool {
 	if l.lastMarkup == Title {
 		return true
@@ -519,12 +791,15 @@ func (l *Scanner) isSectionAdornment(r rune) bool {
 	for r != eof && r != '\n' {
 		r = l.next()
 	}
+	l.skipSpaces()
 	r = l.peek()
 	l.pos, l.lastWidth = pos, lastWidth
 	return r != '\n'
 }
 
 // isSection reports whether the scanner is on a section.
+// The adornment must be an unbroken run of one character, so a simple table
+// border like "=====  =====", whose columns are separated by spaces, is not one.
 func (l *Scanner) isSection(r rune) bool {
 	if !strings.ContainsRune(adornments, r) {
 		return false
@@ -534,9 +809,25 @@ func (l *Scanner) isSection(r rune) bool {
 }
 
 // isHyperlinkStart reports whether the scanner is on a hyperlink start.
+// The anonymous hyperlink start must begin a block: a paragraph line starting
+// with "__ " is paragraph text, whether at column 0 or indented in a list item
+// or block quote, as is a line like "__init__" that lacks the space.
 func (l *Scanner) isHyperlinkStart() bool {
 	s := l.input[l.start:]
-	return strings.HasPrefix(s, hyperlinkStart) || strings.HasPrefix(s, anonHyperlinkStart)
+	if strings.HasPrefix(s, hyperlinkStart) {
+		return true
+	}
+	if !strings.HasPrefix(s, anonHyperlinkStart) {
+		return false
+	}
+	switch {
+	case l.types[1] == Paragraph:
+		return l.lastMarkup != EOF
+	case l.types[1] == Space && l.types[0] == Paragraph:
+		// An indented paragraph line continues a list item or block quote.
+		return false
+	}
+	return true
 }
 
 // isHyperlinkPrefix reports whether the scanner is on a hyperlink prefix.
@@ -575,7 +866,7 @@ func (l *Scanner) isHyperlinkSuffix() bool {
 // isHyperlinkURI reports whether the scanner is on a hyperlink URI.
 func (l *Scanner) isHyperlinkURI() bool {
 	s := strings.TrimSuffix(l.input[l.pos:], "\n")
-	if isUnderscoreSuffix(s) && !strings.ContainsFunc(s, unicode.IsSpace) {
+	if isUnderscoreSuffix(s) && !strings.ContainsFunc(s, isSpace) {
 		return false
 	}
 	switch l.types[0] {
@@ -611,13 +902,16 @@ func (l *Scanner) isInlineReferenceClose() bool {
 }
 
 // isTitle reports whether the scanner is on a title.
+// The current line is always fully loaded, so it jumps straight to the
+// next line instead of stepping through the current one rune by rune.
 func (l *Scanner) isTitle() bool {
-	pos, lastWidth := l.pos, l.lastWidth
-	var r rune
-	for r != eof && r != '\n' {
-		r = l.next()
+	i := strings.IndexByte(l.input[l.pos:], '\n')
+	if i < 0 {
+		return false
 	}
-	r = l.next()
+	pos, lastWidth := l.pos, l.lastWidth
+	l.pos += i + 1
+	r := l.next()
 	if i := strings.IndexFunc(l.input[l.pos:], notSpace); i > 0 {
 		l.pos += i
 		r = l.next()
@@ -5,6 +5,9 @@
 package scan
 
 import (
+	"fmt"
+	"io"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -20,48 +23,49 @@ func item(typ Type, text string) Token {
 }
 
 var (
-	tEOF                   = item(EOF, "EOF")
-	tBlankLine             = item(BlankLine, "\n")
-	tSpace                 = item(Space, " ")
-	tSpace2                = item(Space, "  ")
-	tSpace3                = item(Space, "   ")
-	tSpace4                = item(Space, "    ")
-	tSpace7                = item(Space, "       ")
-	tSectionAdornment2     = item(SectionAdornment, "==")
-	tSectionAdornment3     = item(SectionAdornment, "===")
-	tSectionAdornment5     = item(SectionAdornment, "=====")
-	tSectionAdornment7     = item(SectionAdornment, "=======")
-	tSectionAdornment9     = item(SectionAdornment, "=========")
-	tSectionAdornment24    = item(SectionAdornment, "========================")
-	tSectionAdornmentDash5 = item(SectionAdornment, "-----")
-	tSectionAdornmentDash7 = item(SectionAdornment, "-------")
-	tSectionAdornmentDot3  = item(SectionAdornment, "...")
-	tSectionAdornmentTick7 = item(SectionAdornment, "```````")
-	tTransition24          = item(Transition, "========================")
-	tTransitionDash8       = item(Transition, "--------")
-	tTransitionDash10      = item(Transition, "----------")
-	tBulletAsterisk        = item(Bullet, "*")
-	tBulletPlus            = item(Bullet, "+")
-	tBulletDash            = item(Bullet, "-")
-	tBlockQuote2           = item(BlockQuote, "  ")
-	tBlockQuote3           = item(BlockQuote, "   ")
-	tBlockQuote4           = item(BlockQuote, "    ")
-	tBlockQuote6           = item(BlockQuote, "      ")
-	tComment               = item(Comment, "..")
-	tHyperlinkStart        = item(HyperlinkStart, "..")
-	tAnonHyperlinkStart    = item(HyperlinkStart, "__")
-	tHyperlinkPrefix       = item(HyperlinkPrefix, "_")
-	tAnonHyperlinkPrefix   = item(HyperlinkPrefix, "__")
-	tHyperlinkQuote        = item(HyperlinkQuote, "`")
-	tHyperlinkSuffix       = item(HyperlinkSuffix, ":")
-	tInlineReferenceOpen   = item(InlineReferenceOpen, "`")
-	tInlineReferenceClose1 = item(InlineReferenceClose, "_")
-	tInlineReferenceClose2 = item(InlineReferenceClose, "`_")
+	tEOF                    = item(EOF, "EOF")
+	tBlankLine              = item(BlankLine, "\n")
+	tSpace                  = item(Space, " ")
+	tSpace2                 = item(Space, "  ")
+	tSpace3                 = item(Space, "   ")
+	tSpace4                 = item(Space, "    ")
+	tSpace7                 = item(Space, "       ")
+	tSectionAdornment2      = item(SectionAdornment, "==")
+	tSectionAdornment3      = item(SectionAdornment, "===")
+	tSectionAdornment5      = item(SectionAdornment, "=====")
+	tSectionAdornment7      = item(SectionAdornment, "=======")
+	tSectionAdornment9      = item(SectionAdornment, "=========")
+	tSectionAdornment24     = item(SectionAdornment, "========================")
+	tSectionAdornmentDash5  = item(SectionAdornment, "-----")
+	tSectionAdornmentDash7  = item(SectionAdornment, "-------")
+	tSectionAdornmentDash10 = item(SectionAdornment, "----------")
+	tSectionAdornmentDot3   = item(SectionAdornment, "...")
+	tSectionAdornmentTick7  = item(SectionAdornment, "```````")
+	tTransition24           = item(Transition, "========================")
+	tTransitionDash8        = item(Transition, "--------")
+	tTransitionDash10       = item(Transition, "----------")
+	tBulletAsterisk         = item(Bullet, "*")
+	tBulletPlus             = item(Bullet, "+")
+	tBulletDash             = item(Bullet, "-")
+	tBlockQuote2            = item(BlockQuote, "  ")
+	tBlockQuote3            = item(BlockQuote, "   ")
+	tBlockQuote4            = item(BlockQuote, "    ")
+	tBlockQuote6            = item(BlockQuote, "      ")
+	tComment                = item(Comment, "..")
+	tHyperlinkStart         = item(HyperlinkStart, "..")
+	tAnonHyperlinkStart     = item(HyperlinkStart, "__")
+	tHyperlinkPrefix        = item(HyperlinkPrefix, "_")
+	tAnonHyperlinkPrefix    = item(HyperlinkPrefix, "__")
+	tHyperlinkQuote         = item(HyperlinkQuote, "`")
+	tHyperlinkSuffix        = item(HyperlinkSuffix, ":")
+	tInlineReferenceOpen    = item(InlineReferenceOpen, "`")
+	tInlineReferenceClose1  = item(InlineReferenceClose, "_")
+	tInlineReferenceClose2  = item(InlineReferenceClose, "`_")
 )
 
 var scanTests = []scanTest{
 	{"empty", "", []Token{tEOF}},
-	{"spaces", " \t\n", []Token{item(Space, " \t\n"), tEOF}},
+	{"spaces", " \t\n", []Token{tBlankLine, tEOF}},
 	{"quote error", "`", []Token{item(Error, "expected hyperlink or inline reference before quote")}},
 	{"text", `now is the time`, []Token{item(Paragraph, "now is the time"), tEOF}},
 	// comments
@@ -131,6 +135,23 @@ Paragraph.`,
 			tBlankLine, item(Paragraph, "Paragraph."), tEOF,
 		},
 	},
+	{
+		"empty comment, trailing spaces",
+		"..   \n\nParagraph.",
+		[]Token{tComment, tBlankLine, item(Paragraph, "Paragraph."), tEOF},
+	},
+	{
+		"comment, tab",
+		"..\tA comment.",
+		[]Token{tComment, item(Space, "\t"), item(Paragraph, "A comment."), tEOF},
+	},
+	{
+		"empty comment at end of input",
+		`Paragraph.
+
+..`,
+		[]Token{item(Paragraph, "Paragraph."), tBlankLine, tComment, tEOF},
+	},
 	{
 		"comment with directive",
 		`.. A comment::
@@ -627,6 +648,51 @@ __ http://w3c.org/`,
 			tAnonHyperlinkStart, tSpace, item(HyperlinkURI, "http://w3c.org/"), tEOF,
 		},
 	},
+	{
+		"paragraph starting with underscores",
+		`__init__ is a method.`,
+		[]Token{item(Paragraph, "__init__ is a method."), tEOF},
+	},
+	{
+		"paragraph continuation starting with anonymous hyperlink start",
+		`A paragraph line,
+__ not an anonymous hyperlink target.`,
+		[]Token{
+			item(Paragraph, "A paragraph line,"),
+			item(Paragraph, "__ not an anonymous hyperlink target."), tEOF,
+		},
+	},
+	{
+		"bullet list item continuation starting with anonymous hyperlink start",
+		`- Item line,
+  __ not an anonymous hyperlink target.`,
+		[]Token{
+			tBulletDash, tSpace, item(Paragraph, "Item line,"),
+			tSpace2, item(Paragraph, "__ not an anonymous hyperlink target."), tEOF,
+		},
+	},
+	{
+		"block quote continuation starting with anonymous hyperlink start",
+		`Paragraph.
+
+  Quote line,
+  __ not an anonymous hyperlink target.`,
+		[]Token{
+			item(Paragraph, "Paragraph."), tBlankLine,
+			tBlockQuote2, item(Paragraph, "Quote line,"),
+			tSpace2, item(Paragraph, "__ not an anonymous hyperlink target."), tEOF,
+		},
+	},
+	{
+		"anonymous hyperlink target in bullet list item",
+		`- Item.
+
+  __ http://w3c.org/`,
+		[]Token{
+			tBulletDash, tSpace, item(Paragraph, "Item."), tBlankLine,
+			tSpace2, tAnonHyperlinkStart, tSpace, item(HyperlinkURI, "http://w3c.org/"), tEOF,
+		},
+	},
 	{
 		"anonymous indirect hyperlink target",
 		`Anonymous indirect hyperlink target:
@@ -853,6 +919,19 @@ Test long title and space normalization.`,
 			tBlankLine, item(Paragraph, "Test long title and space normalization."), tEOF,
 		},
 	},
+	{
+		"title, trailing whitespace",
+		"Title  \t\n=====\n\nParagraph.",
+		[]Token{item(Title, "Title"), tSectionAdornment5, tBlankLine, item(Paragraph, "Paragraph."), tEOF},
+	},
+	{
+		"title, over/underline, surrounding whitespace",
+		"=========\n  Title  \n=========\n\nParagraph.",
+		[]Token{
+			tSectionAdornment9, tSpace2, item(Title, "Title"), tSectionAdornment9,
+			tBlankLine, item(Paragraph, "Paragraph."), tEOF,
+		},
+	},
 	{
 		"title, over/underline mismatch",
 		`=======
@@ -1220,6 +1299,28 @@ Ho`,
 =============`,
 		[]Token{item(Title, "Empty Section"), item(SectionAdornment, "============="), tEOF},
 	},
+	{
+		"simple table border",
+		`=====  =====
+A      B
+=====  =====`,
+		[]Token{
+			item(Paragraph, "=====  ====="), item(Paragraph, "A      B"),
+			item(Paragraph, "=====  ====="), tEOF,
+		},
+	},
+	{
+		"simple table border after blank line",
+		`Paragraph.
+
+=====  =====
+A      B
+=====  =====`,
+		[]Token{
+			item(Paragraph, "Paragraph."), tBlankLine, item(Paragraph, "=====  ====="),
+			item(Paragraph, "A      B"), item(Paragraph, "=====  ====="), tEOF,
+		},
+	},
 	{
 		"3 character section titles",
 		`===
@@ -1244,6 +1345,37 @@ Without it, the parser ends up in an infinite loop.`,
 			item(Paragraph, "Without it, the parser ends up in an infinite loop."), tEOF,
 		},
 	},
+	{
+		"2 character section titles, equals signs",
+		`==
+Hi
+==
+
+Text.
+
+Ho
+==
+
+Text.`,
+		[]Token{
+			tSectionAdornment2, item(Title, "Hi"), tSectionAdornment2, tBlankLine,
+			item(Paragraph, "Text."), tBlankLine,
+			item(Title, "Ho"), tSectionAdornment2, tBlankLine,
+			item(Paragraph, "Text."), tEOF,
+		},
+	},
+	{
+		"3 character section titles without blank lines",
+		`===
+One
+===
+Two
+===`,
+		[]Token{
+			tSectionAdornment3, item(Title, "One"), tSectionAdornment3,
+			item(Title, "Two"), tSectionAdornment3, tEOF,
+		},
+	},
 	// bullet lists
 	{
 		"bullet list", "- item",
@@ -1326,6 +1458,26 @@ no blank line`,
 empty item above`,
 		[]Token{tBulletDash, tBlankLine, item(Paragraph, "empty item above"), tEOF},
 	},
+	{
+		"empty bullet at end of input",
+		`*`,
+		[]Token{tBulletAsterisk, tEOF},
+	},
+	{
+		"bullet without space",
+		`*item`,
+		[]Token{item(Paragraph, "*item"), tEOF},
+	},
+	{
+		"emphasis, not bullet",
+		`*emphasis* is not a bullet.`,
+		[]Token{item(Paragraph, "*emphasis* is not a bullet."), tEOF},
+	},
+	{
+		"bullet with space",
+		`* item`,
+		[]Token{tBulletAsterisk, tSpace, item(Paragraph, "item"), tEOF},
+	},
 	{
 		"empty bullet list, no blank line",
 		`-
@@ -1726,6 +1878,26 @@ Princeton, NJ.`,
 			item(Paragraph, "Princeton, NJ."), tEOF,
 		},
 	},
+	{
+		"non-breaking space after enumerator",
+		"1.\u00a0Not an item.\n2.\u00a0Not an item.",
+		[]Token{item(Paragraph, "1.\u00a0Not an item."), item(Paragraph, "2.\u00a0Not an item."), tEOF},
+	},
+	{
+		"non-breaking space after bullet",
+		"-\u00a0Not an item.",
+		[]Token{item(Paragraph, "-\u00a0Not an item."), tEOF},
+	},
+	{
+		"non-breaking space indentation",
+		"Paragraph.\n\u00a0\u00a0Not a block quote.",
+		[]Token{item(Paragraph, "Paragraph."), item(Paragraph, "\u00a0\u00a0Not a block quote."), tEOF},
+	},
+	{
+		"non-breaking space line",
+		"Paragraph 1.\n\u00a0\nParagraph 2.",
+		[]Token{item(Paragraph, "Paragraph 1."), item(Paragraph, "\u00a0"), item(Paragraph, "Paragraph 2."), tEOF},
+	},
 	{
 		"multi-line enumerated list",
 		`1. Item one: line 1,
@@ -1888,6 +2060,63 @@ Line 2.
 			tBlockQuote4, item(Paragraph, "Unexpectedly indented."), tEOF,
 		},
 	},
+	{
+		"comment, blank line, indented text",
+		`.. A comment.
+
+  Still the comment.`,
+		[]Token{
+			tComment, tSpace, item(Paragraph, "A comment."), tBlankLine,
+			tSpace2, item(Paragraph, "Still the comment."), tEOF,
+		},
+	},
+	{
+		"paragraph, blank line, block quote, blank line, indented text",
+		`Paragraph.
+
+  Block quote.
+
+  Still the block quote.`,
+		[]Token{
+			item(Paragraph, "Paragraph."), tBlankLine, tBlockQuote2, item(Paragraph, "Block quote."),
+			tBlankLine, tSpace2, item(Paragraph, "Still the block quote."), tEOF,
+		},
+	},
+	{
+		"title, blank line, block quote",
+		`Title
+=====
+
+  Block quote.`,
+		[]Token{
+			item(Title, "Title"), tSectionAdornment5, tBlankLine,
+			tBlockQuote2, item(Paragraph, "Block quote."), tEOF,
+		},
+	},
+	{
+		"title, indented text",
+		`Title
+=====
+  Indented.`,
+		[]Token{item(Title, "Title"), tSectionAdornment5, tSpace2, item(Paragraph, "Indented."), tEOF},
+	},
+	{
+		"transition, blank line, block quote",
+		`----------
+
+  Block quote.`,
+		[]Token{tTransitionDash10, tBlankLine, tBlockQuote2, item(Paragraph, "Block quote."), tEOF},
+	},
+	{
+		"bullet list, blank line, indented text",
+		`- Item.
+
+  Still the item.`,
+		[]Token{
+			tBulletDash, tSpace, item(Paragraph, "Item."), tBlankLine,
+			tSpace2, item(Paragraph, "Still the item."), tEOF,
+		},
+	},
 	{
 		"no blank line after block quote",
 		`Line 1.
@@ -2080,6 +2309,28 @@ Paragraph.
 			item(Space, "          "), item(Paragraph, "and line three"), tEOF,
 		},
 	},
+	{
+		"attribution dash counts",
+		`Paragraph.
+
+   Block quote.
+
+   ---- Not an attribution
+
+Paragraph.
+
+   Block quote.
+
+   -- Jean-Paul Sartre`,
+		[]Token{
+			item(Paragraph, "Paragraph."), tBlankLine,
+			tBlockQuote3, item(Paragraph, "Block quote."), tBlankLine,
+			tSpace3, item(Paragraph, "---- Not an attribution"), tBlankLine,
+			item(Paragraph, "Paragraph."), tBlankLine,
+			tBlockQuote3, item(Paragraph, "Block quote."), tBlankLine,
+			tSpace3, item(Attribution, "-- Jean-Paul Sartre"), tEOF,
+		},
+	},
 	{
 		"invalid consecutive attribution",
 		`Paragraph.
@@ -2332,11 +2583,149 @@ Some text.`,
 `,
 		[]Token{item(Paragraph, "A paragraph."), tBlankLine, tTransitionDash10, tBlankLine, tEOF},
 	},
+	// period runs: isComment is checked first and only matches ".." followed
+	// by whitespace or the end of the input, isTransition needs at least
+	// minTransition periods between blank lines or the edges of the input,
+	// and any other run of at least minSection periods is a section adornment
+	{"2 periods", "..", []Token{tComment, tEOF}},
+	{"3 periods", "...", []Token{tSectionAdornmentDot3, tEOF}},
+	{"4 periods", "....", []Token{item(Transition, "...."), tEOF}},
+	{"2 periods, text", ".. Text", []Token{tComment, tSpace, item(Paragraph, "Text"), tEOF}},
+	{"2 periods, blank line", "..\n\nText.", []Token{tComment, tBlankLine, item(Paragraph, "Text."), tEOF}},
+	{"2 periods, next line", "..\nText", []Token{tComment, item(Paragraph, "Text"), tEOF}},
+	{"2 empty comments", "..\n..", []Token{tComment, tComment, tEOF}},
+	{
+		"3 periods, blank line",
+		"...\n\nText.",
+		[]Token{tSectionAdornmentDot3, tBlankLine, item(Paragraph, "Text."), tEOF},
+	},
+	{
+		"4 periods, blank line",
+		"....\n\nText.",
+		[]Token{item(Transition, "...."), tBlankLine, item(Paragraph, "Text."), tEOF},
+	},
+	{
+		"transition between paragraphs",
+		"Text.\n\n....\n\nText.",
+		[]Token{
+			item(Paragraph, "Text."), tBlankLine, item(Transition, "...."), tBlankLine,
+			item(Paragraph, "Text."), tEOF,
+		},
+	},
+	{"adjacent 3 periods", "...\n...", []Token{tSectionAdornmentDot3, tSectionAdornmentDot3, tEOF}},
+	{
+		"adjacent 4 periods",
+		"....\n....",
+		[]Token{item(SectionAdornment, "...."), item(SectionAdornment, "...."), tEOF},
+	},
+	{"2 period underline", "Te\n..", []Token{item(Title, "Te"), item(SectionAdornment, ".."), tEOF}},
+	{"3 period underline", "Text.\n...", []Token{item(Title, "Text."), tSectionAdornmentDot3, tEOF}},
+	{
+		"3 period overline and underline",
+		"...\nTitle\n...",
+		[]Token{tSectionAdornmentDot3, item(Title, "Title"), tSectionAdornmentDot3, tEOF},
+	},
+	// adornment runs: a line is a transition only with blank lines or the
+	// edges of the input on both sides
+	{"1 line", "----------", []Token{tTransitionDash10, tEOF}},
+	{"2 adjacent lines", "----------\n----------", []Token{tSectionAdornmentDash10, tSectionAdornmentDash10, tEOF}},
+	{
+		"2 adjacent lines, different characters",
+		"==========\n----------",
+		[]Token{item(SectionAdornment, "=========="), tSectionAdornmentDash10, tEOF},
+	},
+	{
+		"3 adjacent lines",
+		"----------\n----------\n----------",
+		[]Token{tSectionAdornmentDash10, tSectionAdornmentDash10, tSectionAdornmentDash10, tEOF},
+	},
+	{"2 lines, blank line", "----------\n\n----------", []Token{tTransitionDash10, tBlankLine, tTransitionDash10, tEOF}},
+	{
+		"2 lines, blank line, different characters",
+		"==========\n\n----------",
+		[]Token{item(Transition, "=========="), tBlankLine, tTransitionDash10, tEOF},
+	},
+	{
+		"1 line, blank line, 2 adjacent lines",
+		"----------\n\n----------\n----------",
+		[]Token{tTransitionDash10, tBlankLine, tSectionAdornmentDash10, tSectionAdornmentDash10, tEOF},
+	},
+	{
+		"2 adjacent lines, blank line, 1 line",
+		"----------\n----------\n\n----------",
+		[]Token{tSectionAdornmentDash10, tSectionAdornmentDash10, tBlankLine, tTransitionDash10, tEOF},
+	},
+	{
+		"paragraph, blank line, 2 adjacent lines",
+		"Paragraph.\n\n----------\n----------",
+		[]Token{item(Paragraph, "Paragraph."), tBlankLine, tSectionAdornmentDash10, tSectionAdornmentDash10, tEOF},
+	},
+	{
+		"2 short lines, blank line",
+		"--\n\n--",
+		[]Token{item(SectionAdornment, "--"), tBlankLine, item(SectionAdornment, "--"), tEOF},
+	},
+	// transition length: hyphens are a transition from minTransition on,
+	// and a shorter run followed by text starts an attribution instead
+	{
+		"3 hyphens",
+		"Paragraph.\n\n---\n\nParagraph.",
+		[]Token{item(Paragraph, "Paragraph."), tBlankLine, item(Paragraph, "---"), tBlankLine, item(Paragraph, "Paragraph."), tEOF},
+	},
+	{
+		"4 hyphens",
+		"Paragraph.\n\n----\n\nParagraph.",
+		[]Token{item(Paragraph, "Paragraph."), tBlankLine, item(Transition, "----"), tBlankLine, item(Paragraph, "Paragraph."), tEOF},
+	},
+	{
+		"3 hyphens and text, 4 hyphens",
+		"Paragraph.\n\n   Block quote.\n\n   --- Attribution\n\n----\n\nParagraph.",
+		[]Token{
+			item(Paragraph, "Paragraph."), tBlankLine,
+			tBlockQuote3, item(Paragraph, "Block quote."), tBlankLine,
+			tSpace3, item(Attribution, "--- Attribution"), tBlankLine,
+			item(Transition, "----"), tBlankLine, item(Paragraph, "Paragraph."), tEOF,
+		},
+	},
+	{
+		"4 hyphens and text",
+		"Paragraph.\n\n   Block quote.\n\n   ---- Not an attribution",
+		[]Token{
+			item(Paragraph, "Paragraph."), tBlankLine,
+			tBlockQuote3, item(Paragraph, "Block quote."), tBlankLine,
+			tSpace3, item(Paragraph, "---- Not an attribution"), tEOF,
+		},
+	},
+}
+
+func TestTransitionBoundary(t *testing.T) {
+	for _, r := range adornments {
+		// A line of backquotes shorter than a transition starts an inline
+		// reference, which is an error outside a hyperlink target.
+		if r == '`' {
+			continue
+		}
+		for n, typ := range map[int]Type{minTransition - 1: Paragraph, minTransition: Transition} {
+			line := strings.Repeat(string(r), n)
+			test := scanTest{
+				fmt.Sprintf("%d %q", n, r),
+				"Paragraph.\n\n" + line + "\n\nParagraph.",
+				[]Token{item(Paragraph, "Paragraph."), tBlankLine, item(typ, line), tBlankLine, item(Paragraph, "Paragraph."), tEOF},
+			}
+			if diff := diffTokens(collect(&test), test.items); diff != "" {
+				t.Errorf("%s: token mismatch\n%s", test.name, diff)
+			}
+		}
+	}
 }
 
 // collect gathers the emitted items into a slice.
-func collect(t *scanTest) (items []Token) {
-	s := New(t.name, strings.NewReader(t.input))
+func collect(t *scanTest) []Token {
+	return scanAll(New(t.name, strings.NewReader(t.input)))
+}
+
+// scanAll gathers the items emitted by s into a slice.
+func scanAll(s *Scanner) (items []Token) {
 	for {
 		i := s.Next()
 		items = append(items, i)
@@ -2352,24 +2741,678 @@ func equal(i1, i2 []Token, checkPos bool) bool {
 		return false
 	}
 	for k := range i1 {
-		if i1[k].Type != i2[k].Type {
-			return false
-		}
-		if i1[k].Text != i2[k].Text {
+		if !i1[k].Equal(i2[k]) {
 			return false
 		}
-		if checkPos && i1[k].Line != i2[k].Line {
+		if checkPos && !i1[k].EqualLine(i2[k]) {
 			return false
 		}
 	}
 	return true
 }
 
+// diffTokens returns an aligned, line-by-line comparison of got and want
+// with the first divergence marked, or the empty string if they are equal.
+// Positions are not compared.
+func diffTokens(got, want []Token) string {
+	return diff(got, want, false)
+}
+
+// diffTokenLines is like diffTokens but also compares and shows the line
+// of each token.
+func diffTokenLines(got, want []Token) string {
+	return diff(got, want, true)
+}
+
+func diff(got, want []Token, checkPos bool) string {
+	if equal(got, want, checkPos) {
+		return ""
+	}
+	format := func(toks []Token, i int) string {
+		switch {
+		case i >= len(toks):
+			return "-"
+		case checkPos:
+			return fmt.Sprintf("%d:%s %q", toks[i].Line, toks[i].Type, toks[i].Text)
+		}
+		return fmt.Sprintf("%s %q", toks[i].Type, toks[i].Text)
+	}
+	n := max(len(got), len(want))
+	var width int
+	for i := range n {
+		width = max(width, len(format(got, i)))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "  %3s  %-*s  %s\n", "", width, "got", "want")
+	diverged := false
+	for i := range n {
+		g, w := format(got, i), format(want, i)
+		mark := " "
+		if !diverged && g != w {
+			mark = ">"
+			diverged = true
+		}
+		fmt.Fprintf(&b, "%s %3d  %-*s  %s\n", mark, i, width, g, w)
+	}
+	return b.String()
+}
+
 func TestScan(t *testing.T) {
 	for _, test := range scanTests {
 		items := collect(&test)
-		if !equal(items, test.items, false) {
-			t.Fatalf("%s: got\n\t%+v\nexpected\n\t%v", test.name, items, test.items)
+		if diff := diffTokens(items, test.items); diff != "" {
+			t.Fatalf("%s: token mismatch\n%s", test.name, diff)
+		}
+	}
+}
+
+func TestDiffTokens(t *testing.T) {
+	want := []Token{item(Paragraph, "Paragraph."), tBlankLine, tEOF}
+	if diff := diffTokens(want, want); diff != "" {
+		t.Errorf("diffTokens of equal slices = %q, expected empty", diff)
+	}
+	got := []Token{item(Paragraph, "Paragraph."), tEOF}
+	expected := `       got                     want
+    0  Paragraph "Paragraph."  Paragraph "Paragraph."
+>   1  EOF "EOF"               BlankLine "\n"
+    2  -                       EOF "EOF"
+`
+	if diff := diffTokens(got, want); diff != expected {
+		t.Errorf("diffTokens =\n%s\nexpected\n%s", diff, expected)
+	}
+	got, want = []Token{{Paragraph, 2, "Paragraph."}}, []Token{{Paragraph, 1, "Paragraph."}}
+	expected = `       got                       want
+>   0  2:Paragraph "Paragraph."  1:Paragraph "Paragraph."
+`
+	if diff := diffTokenLines(got, want); diff != expected {
+		t.Errorf("diffTokenLines =\n%s\nexpected\n%s", diff, expected)
+	}
+}
+
+func TestRest(t *testing.T) {
+	s := New("rest", strings.NewReader("- item\n- another"))
+	for _, want := range []Token{tBulletDash, tSpace} {
+		if got := s.Next(); !equal([]Token{got}, []Token{want}, false) {
+			t.Fatalf("got %v, expected %v", got, want)
+		}
+	}
+	if got, want := s.Rest(), "item\n"; got != want {
+		t.Errorf("Rest() = %q, expected %q", got, want)
+	}
+	s = New("quote error", strings.NewReader("Para.\n`x more"))
+	for _, want := range []Token{item(Paragraph, "Para."), item(Error, "expected hyperlink or inline reference before quote")} {
+		if got := s.Next(); !equal([]Token{got}, []Token{want}, false) {
+			t.Fatalf("got %v, expected %v", got, want)
+		}
+	}
+	if got, want := s.Rest(), "`x more"; got != want {
+		t.Errorf("Rest() after error = %q, expected %q", got, want)
+	}
+}
+
+func TestIndent(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		indents []int
+	}{
+		{"nested list body", "- Item.\n\n  - Nested.\n\n    Body.", []int{0, 2, 4}},
+		{"whitespace-only lines", "Para.\n   \n   \n   x", []int{0, 3}},
+		{"tabs", "- Item.\n\n\tBody.\n\n  \tMore.\n\n\t  Most.", []int{0, 8, 8, 10}},
+		{"unindented line after list body", "- Item.\n\n  Body.\nZero line.", []int{0, 2, 0}},
+		{"attribution", "Para.\n\n   Quote.\n\n   -- Sartre", []int{0, 3, 3}},
+	}
+	for _, test := range tests {
+		s := New(test.name, strings.NewReader(test.input))
+		var indents []int
+		for tok := s.Next(); tok.Type != EOF && tok.Type != Error; tok = s.Next() {
+			if tok.Type == Paragraph || tok.Type == Attribution {
+				indents = append(indents, s.Indent())
+			}
+		}
+		if !slices.Equal(indents, test.indents) {
+			t.Errorf("%s: paragraph and attribution indents = %v, expected %v", test.name, indents, test.indents)
+		}
+	}
+}
+
+func TestTokenEqual(t *testing.T) {
+	tests := []struct {
+		t1, t2          Token
+		equal, sameLine bool
+	}{
+		{Token{Paragraph, 1, "text"}, Token{Paragraph, 1, "text"}, true, true},
+		{Token{Paragraph, 1, "text"}, Token{Paragraph, 2, "text"}, true, false},
+		{Token{Paragraph, 1, "text"}, Token{Title, 1, "text"}, false, false},
+		{Token{Paragraph, 1, "text"}, Token{Paragraph, 1, "other"}, false, false},
+	}
+	for _, test := range tests {
+		if got := test.t1.Equal(test.t2); got != test.equal {
+			t.Errorf("%v.Equal(%v) = %t, expected %t", test.t1, test.t2, got, test.equal)
+		}
+		if got := test.t1.EqualLine(test.t2); got != test.sameLine {
+			t.Errorf("%v.EqualLine(%v) = %t, expected %t", test.t1, test.t2, got, test.sameLine)
+		}
+		if got := test.t1.Key() == test.t2.Key(); got != test.equal {
+			t.Errorf("%v.Key() == %v.Key() is %t, expected %t", test.t1, test.t2, got, test.equal)
+		}
+	}
+}
+
+func TestVerifyLines(t *testing.T) {
+	for _, test := range scanTests {
+		func() {
+			defer func() {
+				if e := recover(); e != nil {
+					t.Error(e)
+				}
+			}()
+			s := New(test.name, strings.NewReader(test.input))
+			s.Verify()
+			scanAll(s)
+		}()
+	}
+}
+
+func TestDisable(t *testing.T) {
+	tests := []struct {
+		name     string
+		disabled Construct
+		input    string
+		items    []Token
+	}{
+		{
+			"enumerated list",
+			Enums,
+			"1. Item one.\n2. Item two.",
+			[]Token{item(Paragraph, "1. Item one."), item(Paragraph, "2. Item two."), tEOF},
+		},
+		{
+			"enumerated list enabled",
+			Bullets,
+			"1. Item.",
+			[]Token{item(Enum, "1."), tSpace, item(Paragraph, "Item."), tEOF},
+		},
+		{"bullet list", Bullets, "- Item.", []Token{item(Paragraph, "- Item."), tEOF}},
+		{"comment", Comments, ".. A comment.", []Token{item(Paragraph, ".. A comment."), tEOF}},
+		{"empty comment", Comments, "..", []Token{item(Paragraph, ".."), tEOF}},
+		{"transition", Transitions, "----------", []Token{item(Paragraph, "----------"), tEOF}},
+		{
+			"transition between paragraphs",
+			Transitions,
+			"Paragraph.\n\n----------\n\nParagraph.",
+			[]Token{
+				item(Paragraph, "Paragraph."), tBlankLine, item(Paragraph, "----------"),
+				tBlankLine, item(Paragraph, "Paragraph."), tEOF,
+			},
+		},
+		{
+			"hyperlink target",
+			Hyperlinks,
+			".. _target: http://example.org/",
+			[]Token{item(Paragraph, ".. _target: http://example.org/"), tEOF},
+		},
+		{"anonymous hyperlink target", Hyperlinks, "__ http://example.org/", []Token{item(Paragraph, "__ http://example.org/"), tEOF}},
+		{
+			"section",
+			Sections,
+			"Title\n=====",
+			[]Token{item(Paragraph, "Title"), item(Paragraph, "====="), tEOF},
+		},
+		{
+			"block quote",
+			BlockQuotes,
+			"Paragraph.\n\n  Indented.",
+			[]Token{item(Paragraph, "Paragraph."), tBlankLine, tSpace2, item(Paragraph, "Indented."), tEOF},
+		},
+	}
+	for _, test := range tests {
+		s := New(test.name, strings.NewReader(test.input))
+		s.Disable(test.disabled)
+		if diff := diffTokens(scanAll(s), test.items); diff != "" {
+			t.Errorf("%s: token mismatch\n%s", test.name, diff)
+		}
+	}
+}
+
+func TestCoalesceParagraphs(t *testing.T) {
+	tests := []scanTest{
+		{
+			"3 line paragraph",
+			`Line 1.
+Line 2.
+Line 3.`,
+			[]Token{item(Paragraph, "Line 1.\nLine 2.\nLine 3."), tEOF},
+		},
+		{
+			"2 paragraphs",
+			`Paragraph 1.
+
+Paragraph 2.`,
+			[]Token{item(Paragraph, "Paragraph 1."), tBlankLine, item(Paragraph, "Paragraph 2."), tEOF},
+		},
+		{
+			"bullet list item",
+			`- Line 1,
+  line 2.`,
+			[]Token{tBulletDash, tSpace, item(Paragraph, "Line 1,\nline 2."), tEOF},
+		},
+		{
+			"block quote",
+			`Paragraph.
+
+   Line 1,
+   line 2.`,
+			[]Token{item(Paragraph, "Paragraph."), tBlankLine, tBlockQuote3, item(Paragraph, "Line 1,\nline 2."), tEOF},
+		},
+		{
+			"comment, paragraph",
+			`.. A comment
+no blank line`,
+			[]Token{tComment, tSpace, item(Paragraph, "A comment"), item(Paragraph, "no blank line"), tEOF},
+		},
+		{
+			"2 line comment",
+			`.. Line 1,
+   line 2.`,
+			[]Token{tComment, tSpace, item(Paragraph, "Line 1,\nline 2."), tEOF},
+		},
+		{
+			"whitespace-only line",
+			"Para one.\n   \nPara two.",
+			[]Token{item(Paragraph, "Para one."), tBlankLine, item(Paragraph, "Para two."), tEOF},
+		},
+		{
+			"bullet list item, whitespace-only line",
+			"- a\n  \n  b",
+			[]Token{tBulletDash, tSpace, item(Paragraph, "a"), tBlankLine, tSpace2, item(Paragraph, "b"), tEOF},
+		},
+		{
+			"paragraph, indented line",
+			`Line 1,
+  line 2.`,
+			[]Token{item(Paragraph, "Line 1,"), tBlockQuote2, item(Paragraph, "line 2."), tEOF},
+		},
+	}
+	for _, test := range tests {
+		items := CoalesceParagraphs(collect(&test))
+		if diff := diffTokens(items, test.items); diff != "" {
+			t.Errorf("%s: token mismatch\n%s", test.name, diff)
+		}
+	}
+	// A Space token holding a whitespace-only line ends the run.
+	toks := []Token{{Paragraph, 1, "Para one."}, {Space, 2, "   \n"}, {Paragraph, 3, "Para two."}, {EOF, 3, "EOF"}}
+	if diff := diffTokens(CoalesceParagraphs(toks), toks); diff != "" {
+		t.Errorf("space spanning a line break: token mismatch\n%s", diff)
+	}
+}
+
+func TestJoinName(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		join  string
+	}{
+		{"one line", ".. _target: http://example.org/", "target"},
+		{
+			"split regular",
+			".. _a very long target name,\n   split across lines:",
+			"a very long target name, split across lines",
+		},
+		{
+			"split backquoted",
+			".. _`and another,\n   with backquotes`:",
+			"and another, with backquotes",
+		},
+		{"internal whitespace", ".. _a   target\tname: http://example.org/", "a target name"},
+		{"2 targets", ".. _a: http://example.org/a\n.. _b: http://example.org/b", "a"},
+	}
+	for _, test := range tests {
+		if got := JoinName(scanAll(New(test.name, strings.NewReader(test.input)))); got != test.join {
+			t.Errorf("%s: JoinName() = %q, expected %q", test.name, got, test.join)
+		}
+	}
+}
+
+func TestJoinURI(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		uri   string
+	}{
+		{"one line", ".. _a: http://example.org/", "http://example.org/"},
+		{
+			"continued lines",
+			".. _a: http://example.org/\n   a/b.html",
+			"http://example.org/a/b.html",
+		},
+		{
+			"escaped line end",
+			".. _a: http://example.org/a\\ path\\ with\\\n   spaces.html",
+			"http://example.org/a path with spaces.html",
+		},
+		{"escaped underscore", `.. _a: uri\_`, "uri_"},
+		{"2 targets", ".. _a: http://example.org/a\n\n.. _b: http://example.org/b", "http://example.org/a"},
+	}
+	for _, test := range tests {
+		if got := JoinURI(scanAll(New(test.name, strings.NewReader(test.input)))); got != test.uri {
+			t.Errorf("%s: JoinURI() = %q, expected %q", test.name, got, test.uri)
+		}
+	}
+}
+
+func TestEnumSequence(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		enums []enum
+	}{
+		{
+			"auto-enumeration first",
+			"#. Item one.\n#. Item two.",
+			[]enum{{arabic, 1, true}, {arabic, 2, true}},
+		},
+		{"arabic", "1. Item one.\n#. Item two.", []enum{{arabic, 1, false}, {arabic, 2, true}}},
+		{
+			"upper alpha",
+			"A. Item one.\n#. Item two.",
+			[]enum{{upperAlpha, 1, false}, {upperAlpha, 2, true}},
+		},
+		{
+			"lower alpha",
+			"b. Item one.\n#. Item two.",
+			[]enum{{lowerAlpha, 2, false}, {lowerAlpha, 3, true}},
+		},
+		{
+			"upper roman",
+			"I. Item one.\n#. Item two.",
+			[]enum{{upperRoman, 1, false}, {upperRoman, 2, true}},
+		},
+		{
+			"lower roman",
+			"iii. Item one.\niv. Item two.\n#. Item three.",
+			[]enum{{lowerRoman, 3, false}, {lowerRoman, 4, false}, {lowerRoman, 5, true}},
+		},
+	}
+	for _, test := range tests {
+		s := New(test.name, strings.NewReader(test.input))
+		var enums []enum
+		for {
+			i := s.Next()
+			if i.Type == EOF || i.Type == Error {
+				break
+			}
+			if i.Type == Enum {
+				enums = append(enums, s.lastEnum)
+			}
+		}
+		if !slices.Equal(enums, test.enums) {
+			t.Errorf("%s: got %+v, expected %+v", test.name, enums, test.enums)
+		}
+	}
+}
+
+func TestParseRoman(t *testing.T) {
+	tests := []struct {
+		s  string
+		n  int
+		ok bool
+	}{
+		{"i", 1, true},
+		{"IV", 4, true},
+		{"ix", 9, true},
+		{"XIV", 14, true},
+		{"XL", 40, true},
+		{"MCMXCIV", 1994, true},
+		{"IIII", 0, false},
+		{"IC", 0, false},
+	}
+	for _, test := range tests {
+		if n, ok := parseRoman(test.s); n != test.n || ok != test.ok {
+			t.Errorf("parseRoman(%q) = %d, %t, expected %d, %t", test.s, n, ok, test.n, test.ok)
+		}
+	}
+}
+
+func TestTrailingBlankLines(t *testing.T) {
+	tests := []scanTest{
+		{"paragraph", "Paragraph.", []Token{item(Paragraph, "Paragraph.")}},
+		{"title", "Title\n=====", []Token{item(Title, "Title"), tSectionAdornment5}},
+		{"transition", "----------", []Token{tTransitionDash10}},
+		{"bullet list", "- Item.", []Token{tBulletDash, tSpace, item(Paragraph, "Item.")}},
+		{"comment", ".. A comment.", []Token{tComment, tSpace, item(Paragraph, "A comment.")}},
+		{
+			"block quote",
+			"Paragraph.\n\n  Block quote.",
+			[]Token{item(Paragraph, "Paragraph."), tBlankLine, tBlockQuote2, item(Paragraph, "Block quote.")},
+		},
+	}
+	for _, test := range tests {
+		for _, blank := range []string{"\n", "  \n", "\t\n"} {
+			for n := range 4 {
+				input := test.input + "\n" + strings.Repeat(blank, n)
+				items := scanAll(New(test.name, strings.NewReader(input)))
+				want := slices.Clone(test.items)
+				for range n {
+					want = append(want, tBlankLine)
+				}
+				want = append(want, tEOF)
+				if diff := diffTokens(items, want); diff != "" {
+					t.Errorf("%s, %d trailing %q lines: token mismatch\n%s", test.name, n, blank, diff)
+				}
+			}
+		}
+	}
+}
+
+func TestErr(t *testing.T) {
+	s := New("quote error", strings.NewReader("`"))
+	scanAll(s)
+	want := "quote error:1: expected hyperlink or inline reference before quote"
+	if err := s.Err(); err == nil || err.Error() != want {
+		t.Errorf("Err() = %v, expected %s", err, want)
+	}
+	s = New("text", strings.NewReader("now is the time"))
+	scanAll(s)
+	if err := s.Err(); err != nil {
+		t.Errorf("Err() = %v, expected nil", err)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	const chunk = 3
+	for _, test := range scanTests {
+		var items []Token
+		w := NewWriter(test.name, func(tok Token) {
+			items = append(items, tok)
+		})
+		for in := test.input; in != ""; {
+			n := min(chunk, len(in))
+			if _, err := io.WriteString(w, in[:n]); err != nil {
+				break
+			}
+			in = in[n:]
+		}
+		err := w.Close()
+		if diff := diffTokens(items, test.items); diff != "" {
+			t.Fatalf("%s: token mismatch\n%s", test.name, diff)
+		}
+		if wantErr := test.items[len(test.items)-1].Type == Error; (err != nil) != wantErr {
+			t.Errorf("%s: Close() = %v, expected error %t", test.name, err, wantErr)
+		}
+	}
+}
+
+func BenchmarkScanParagraphs(b *testing.B) {
+	var sb strings.Builder
+	for i := range 1000 {
+		fmt.Fprintf(&sb, "Paragraph %d is prose that runs on for a while without any markup,\n", i)
+		sb.WriteString("and it continues on a second line that is just as plain as the first.\n\n")
+	}
+	input := sb.String()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		s := New("bench", strings.NewReader(input))
+		for {
+			if i := s.Next(); i.Type == EOF || i.Type == Error {
+				break
+			}
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, test := range scanTests {
+		if test.items[len(test.items)-1].Type != EOF {
+			continue
+		}
+		if err := Validate(test.items); err != nil {
+			t.Errorf("%s: Validate() = %v, expected nil", test.name, err)
+		}
+	}
+	invalid := []struct {
+		name  string
+		items []Token
+	}{
+		{"empty", nil},
+		{"no EOF", []Token{item(Paragraph, "Paragraph.")}},
+		{"early EOF", []Token{tEOF, item(Paragraph, "Paragraph."), tEOF}},
+		{"error", []Token{item(Error, "expected hyperlink or inline reference before quote")}},
+		{
+			"unmatched inline reference open",
+			[]Token{tAnonHyperlinkStart, tSpace, tInlineReferenceOpen, item(InlineReferenceText, "reference"), tEOF},
+		},
+		{
+			"unmatched inline reference close",
+			[]Token{tAnonHyperlinkStart, tSpace, item(InlineReferenceText, "reference"), tInlineReferenceClose2, tEOF},
+		},
+		{"hyperlink name without prefix", []Token{tHyperlinkStart, tSpace, item(HyperlinkName, "target"), tEOF}},
+		{"hyperlink suffix without name", []Token{item(Paragraph, "Paragraph."), tHyperlinkSuffix, tEOF}},
+		{"hyperlink URI without target", []Token{tSpace, item(HyperlinkURI, "http://example.org/"), tEOF}},
+	}
+	for _, test := range invalid {
+		if err := Validate(test.items); err == nil {
+			t.Errorf("%s: Validate() = nil, expected error", test.name)
+		}
+	}
+}
+
+func TestLeadingBlankLines(t *testing.T) {
+	tests := []scanTest{
+		{"paragraph", "Paragraph.", []Token{{Paragraph, 1, "Paragraph."}}},
+		{"title", "Title\n=====", []Token{{Title, 1, "Title"}, {SectionAdornment, 2, "====="}}},
+		{
+			"title, over/underline",
+			"=====\nTitle\n=====",
+			[]Token{{SectionAdornment, 1, "====="}, {Title, 2, "Title"}, {SectionAdornment, 3, "====="}},
+		},
+		{
+			"transition",
+			"----------\n\nParagraph.",
+			[]Token{{Transition, 1, "----------"}, {BlankLine, 2, "\n"}, {Paragraph, 3, "Paragraph."}},
+		},
+		{
+			"enumerated list",
+			"1. Item one.\n2. Item two.",
+			[]Token{
+				{Enum, 1, "1."}, {Space, 1, " "}, {Paragraph, 1, "Item one."},
+				{Enum, 2, "2."}, {Space, 2, " "}, {Paragraph, 2, "Item two."},
+			},
+		},
+		{"comment", ".. A comment.", []Token{{Comment, 1, ".."}, {Space, 1, " "}, {Paragraph, 1, "A comment."}}},
+	}
+	for _, test := range tests {
+		for _, blank := range []string{"\n", "  \n"} {
+			for n := range 3 {
+				input := strings.Repeat(blank, n) + test.input
+				var want []Token
+				for i := range n {
+					want = append(want, Token{BlankLine, i + 1, "\n"})
+				}
+				for _, i := range test.items {
+					want = append(want, Token{i.Type, i.Line + n, i.Text})
+				}
+				last := want[len(want)-1]
+				want = append(want, Token{EOF, last.Line, "EOF"})
+				items := scanAll(New(test.name, strings.NewReader(input)))
+				if diff := diffTokenLines(items, want); diff != "" {
+					t.Errorf("%s, %d leading %q lines: token mismatch\n%s", test.name, n, blank, diff)
+				}
+			}
+		}
+	}
+}
+
+func TestNextBlock(t *testing.T) {
+	const input = `=====
+Title
+=====
+
+Paragraph.
+
+- Item 1.
+- Item 2.
+
+Section
+-------
+
+1. Item 1,
+   line 2.
+
+----------
+
+.. A comment.
+
+.. _target: http://example.org
+
+Paragraph.
+
+  Block quote.`
+	want := []Token{
+		item(Title, "Title"), tBulletDash, tBulletDash, item(Title, "Section"),
+		item(Enum, "1."), tTransitionDash10, tComment, tHyperlinkStart, tBlockQuote2, tEOF,
+	}
+	s := New("blocks", strings.NewReader(input))
+	var items []Token
+	for {
+		i := s.NextBlock()
+		items = append(items, i)
+		if i.Type == EOF || i.Type == Error {
+			break
+		}
+	}
+	if diff := diffTokens(items, want); diff != "" {
+		t.Errorf("token mismatch\n%s", diff)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name  string
+		enc   Encoding
+		input string
+		items []Token
+	}{
+		{"UTF-8", UTF8, "café", []Token{item(Paragraph, "café"), tEOF}},
+		{
+			"Latin-1",
+			Latin1,
+			"caf\xe9\n====\n\nna\xefve",
+			[]Token{item(Title, "café"), item(SectionAdornment, "===="), tBlankLine, item(Paragraph, "naïve"), tEOF},
+		},
+		{"UTF-16LE", UTF16LE, "\xff\xfec\x00a\x00f\x00\xe9\x00", []Token{item(Paragraph, "café"), tEOF}},
+		{"UTF-16BE", UTF16BE, "\x00c\x00a\x00f\x00\xe9", []Token{item(Paragraph, "café"), tEOF}},
+		{"UTF-16 surrogate pair", UTF16LE, "=\x00 \x00=\xd8\x00\xde", []Token{item(Paragraph, "= 😀"), tEOF}},
+		{"UTF-16 unpaired high surrogate", UTF16LE, "\x00\xd8a\x00b\x00", []Token{item(Paragraph, "\ufffdab"), tEOF}},
+		{"UTF-16 unpaired low surrogate", UTF16BE, "\x00a\xdc\x00\x00b", []Token{item(Paragraph, "a\ufffdb"), tEOF}},
+		{"UTF-16 high surrogate at end", UTF16LE, "a\x00\x00\xd8", []Token{item(Paragraph, "a\ufffd"), tEOF}},
+		{"UTF-16 odd trailing byte", UTF16LE, "a\x00b", []Token{item(Paragraph, "a\ufffd"), tEOF}},
+	}
+	for _, test := range tests {
+		s := New(test.name, Decode(strings.NewReader(test.input), test.enc))
+		if diff := diffTokens(scanAll(s), test.items); diff != "" {
+			t.Errorf("%s: token mismatch\n%s", test.name, diff)
 		}
 	}
 }
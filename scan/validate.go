@@ -0,0 +1,66 @@
+// Copyright 2023 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// predecessors lists the types that may come before a token of the given type,
+// ignoring any Space tokens in between.
+var predecessors = map[Type][]Type{
+	HyperlinkPrefix:      {HyperlinkStart},
+	HyperlinkQuote:       {HyperlinkPrefix, HyperlinkName},
+	HyperlinkName:        {HyperlinkPrefix, HyperlinkQuote, HyperlinkName},
+	HyperlinkSuffix:      {HyperlinkPrefix, HyperlinkQuote, HyperlinkName},
+	HyperlinkURI:         {HyperlinkStart, HyperlinkSuffix, HyperlinkURI},
+	InlineReferenceText:  {HyperlinkStart, HyperlinkSuffix, InlineReferenceOpen, InlineReferenceText},
+	InlineReferenceClose: {InlineReferenceText},
+}
+
+// Validate checks that toks is a well-formed token stream: it ends with
+// its only EOF token, the parts of each hyperlink target appear in order,
+// and every inline reference opened with a quote is closed with one.
+// A stream ending in an Error token is reported as an error.
+func Validate(toks []Token) error {
+	if len(toks) == 0 {
+		return errors.New("empty token stream")
+	}
+	prev := EOF
+	open := false
+	for i, t := range toks {
+		switch t.Type {
+		case EOF:
+			if i != len(toks)-1 {
+				return fmt.Errorf("line %d: EOF before end of token stream", t.Line)
+			}
+		case Error:
+			return fmt.Errorf("line %d: %s", t.Line, t.Text)
+		case InlineReferenceOpen:
+			open = true
+		case InlineReferenceClose:
+			if quoted := strings.HasPrefix(t.Text, "`"); quoted != open {
+				return fmt.Errorf("line %d: %v does not match an inline reference open", t.Line, t)
+			}
+			open = false
+		}
+		if open && t.Type != InlineReferenceOpen && t.Type != InlineReferenceText && t.Type != Space {
+			return fmt.Errorf("line %d: %v: unclosed inline reference", t.Line, t)
+		}
+		if types, ok := predecessors[t.Type]; ok && !slices.Contains(types, prev) {
+			return fmt.Errorf("line %d: %v cannot follow %s", t.Line, t, prev)
+		}
+		if t.Type != Space {
+			prev = t.Type
+		}
+	}
+	if t := toks[len(toks)-1]; t.Type != EOF {
+		return fmt.Errorf("line %d: token stream ends with %v, not EOF", t.Line, t)
+	}
+	return nil
+}
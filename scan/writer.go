@@ -0,0 +1,58 @@
+// Copyright 2023 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scan
+
+import (
+	"bufio"
+	"io"
+)
+
+// Writer is a push-style scanner: input written to it is scanned as it
+// arrives and each token is passed to a callback.
+type Writer struct {
+	pw   *io.PipeWriter
+	done chan struct{} // closed once the final token has been passed on
+	err  error         // error reported by the scanner, if any
+}
+
+// NewWriter creates and returns a new Writer that calls fn with each token.
+// The last token passed to fn is an EOF or Error token. fn is called from a
+// separate goroutine, but never concurrently, and tokens that need lookahead,
+// such as a title, are only passed on once the following line is written.
+// The caller must call Close when done writing; otherwise the scanning
+// goroutine and its pipe are never released.
+func NewWriter(name string, fn func(Token)) *Writer {
+	pr, pw := io.Pipe()
+	w := &Writer{pw: pw, done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+		s := New(name, bufio.NewReader(pr))
+		for {
+			t := s.Next()
+			fn(t)
+			if t.Type == EOF || t.Type == Error {
+				break
+			}
+		}
+		w.err = s.Err()
+		pr.CloseWithError(w.err)
+	}()
+	return w
+}
+
+// Write writes p to the scanner. It blocks until the scanner has read p.
+// After the scanner returns an Error token, Write returns that error.
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+// Close ends the input and waits for the remaining tokens to be passed on.
+// Until Close is called, the scanning goroutine waits for more input.
+// It returns the error reported by the scanner, if any.
+func (w *Writer) Close() error {
+	w.pw.Close()
+	<-w.done
+	return w.err
+}
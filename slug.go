@@ -0,0 +1,45 @@
+// Copyright 2023 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rst
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SlugifyTitle returns an anchor for a section title. The title is lowercased
+// and each run of characters other than letters and digits becomes a hyphen.
+// seen counts the anchors returned so far and must not be nil. An anchor that
+// was already returned gets the smallest unused numeric suffix, so identically
+// titled sections get distinct anchors.
+func SlugifyTitle(title string, seen map[string]int) string {
+	var b strings.Builder
+	sep := false
+	for _, r := range strings.ToLower(title) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			sep = true
+			continue
+		}
+		if sep && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		sep = false
+		b.WriteRune(r)
+	}
+	slug := b.String()
+	if slug == "" {
+		slug = "section"
+	}
+	s := slug
+	for n := seen[slug]; seen[s] > 0; n++ {
+		s = slug + "-" + strconv.Itoa(n)
+	}
+	seen[slug]++
+	if s != slug {
+		seen[s]++
+	}
+	return s
+}
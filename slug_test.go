@@ -0,0 +1,36 @@
+// Copyright 2023 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rst
+
+import (
+	"slices"
+	"testing"
+)
+
+var slugTests = []struct {
+	name   string
+	titles []string
+	slugs  []string
+}{
+	{"title", []string{"Title"}, []string{"title"}},
+	{"spaces and punctuation", []string{"  Long    Title, Again!  "}, []string{"long-title-again"}},
+	{"non-ASCII", []string{"À with combining varia"}, []string{"à-with-combining-varia"}},
+	{"no letters or digits", []string{"***"}, []string{"section"}},
+	{"duplicate titles", []string{"Title", "Title", "Title"}, []string{"title", "title-1", "title-2"}},
+	{"suffix collision", []string{"Title", "Title 1", "Title"}, []string{"title", "title-1", "title-2"}},
+}
+
+func TestSlugifyTitle(t *testing.T) {
+	for _, test := range slugTests {
+		seen := make(map[string]int)
+		var slugs []string
+		for _, title := range test.titles {
+			slugs = append(slugs, SlugifyTitle(title, seen))
+		}
+		if !slices.Equal(slugs, test.slugs) {
+			t.Errorf("%s: got %q, expected %q", test.name, slugs, test.slugs)
+		}
+	}
+}